@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// WithOutputSchema constrains a generate call's response to schema. It's the
+// provider-agnostic counterpart to provider-specific options like
+// googleai.WithResponseSchema: providers that support structured output read
+// Output.Schema off the request and configure their native response-schema
+// field accordingly; providers that don't simply ignore it, leaving
+// [GenerateTyped]'s validate-and-retry loop to catch a malformed response.
+func WithOutputSchema(schema *jsonschema.Schema) GenerateOption {
+	return func(req *GenerateRequest) error {
+		req.Output = &GenerateRequestOutput{Format: OutputFormatJSON, Schema: schema}
+		return nil
+	}
+}
+
+// GenerateTyped calls Generate against model, deriving a JSON schema from T
+// via reflection and constraining the response to it, then unmarshals the
+// first candidate's text into a new *T. If the response doesn't validate
+// against the schema, GenerateTyped retries the call once with the
+// validator's error message appended to the prompt.
+func GenerateTyped[T any](ctx context.Context, model Model, opts ...GenerateOption) (*T, error) {
+	schema := jsonschema.Reflect(new(T))
+	opts = append(opts, WithOutputSchema(schema))
+
+	out, verr := generateTypedOnce[T](ctx, model, schema, opts)
+	if verr == nil {
+		return out, nil
+	}
+
+	retryOpts := append(opts, WithTextPrompt(fmt.Sprintf(
+		"Your previous response did not match the required JSON schema: %s\n"+
+			"Return only JSON matching the schema, with no other text.", verr)))
+	return generateTypedOnce[T](ctx, model, schema, retryOpts)
+}
+
+func generateTypedOnce[T any](ctx context.Context, model Model, schema *jsonschema.Schema, opts []GenerateOption) (*T, error) {
+	resp, err := Generate(ctx, model, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Message.Content) == 0 {
+		return nil, fmt.Errorf("ai.GenerateTyped: empty response")
+	}
+	text := resp.Candidates[0].Message.Content[0].Text
+
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewStringLoader(text))
+	if err != nil {
+		return nil, fmt.Errorf("ai.GenerateTyped: validating response: %w", err)
+	}
+	if !result.Valid() {
+		msgs := make([]string, len(result.Errors()))
+		for i, e := range result.Errors() {
+			msgs[i] = e.String()
+		}
+		return nil, fmt.Errorf("ai.GenerateTyped: response does not match schema: %s", strings.Join(msgs, "; "))
+	}
+
+	var out T
+	if err := json.Unmarshal([]byte(text), &out); err != nil {
+		return nil, fmt.Errorf("ai.GenerateTyped: unmarshaling response: %w", err)
+	}
+	return &out, nil
+}
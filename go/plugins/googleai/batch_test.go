@@ -0,0 +1,45 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+func TestBatchDocuments(t *testing.T) {
+	docs := make([]*ai.Document, 7)
+	for i := range docs {
+		docs[i] = &ai.Document{}
+	}
+
+	batches := batchDocuments(docs, 3)
+
+	wantSizes := []int{3, 3, 1}
+	if len(batches) != len(wantSizes) {
+		t.Fatalf("got %d batches, want %d", len(batches), len(wantSizes))
+	}
+	var total int
+	for i, b := range batches {
+		if len(b) != wantSizes[i] {
+			t.Errorf("batch %d: got size %d, want %d", i, len(b), wantSizes[i])
+		}
+		total += len(b)
+	}
+	if total != len(docs) {
+		t.Errorf("got %d total documents across batches, want %d", total, len(docs))
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// defaultEmbedBatchSize is the largest number of documents sent in a single
+// BatchEmbedContents call when Config.EmbedBatchSize is unset.
+const defaultEmbedBatchSize = 100
+
+// embed embeds input.Documents, splitting them into batches of at most
+// cfg.EmbedBatchSize documents and, when cfg.EmbedMaxConcurrency > 1,
+// sending batches concurrently.
+func embed(ctx context.Context, client *genai.Client, cfg *Config, model string, input *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+	em := client.EmbeddingModel(model)
+	opts, _ := input.Options.(*EmbedOptions)
+	if opts != nil {
+		if opts.TaskType != "" {
+			em.TaskType = genai.TaskType(opts.TaskType)
+		}
+		if opts.OutputDimensionality != 0 {
+			em.OutputDimensionality = int32(opts.OutputDimensionality)
+		}
+	}
+
+	batchSize := defaultEmbedBatchSize
+	concurrency := 1
+	retry := defaultRetryPolicy
+	if cfg != nil {
+		if cfg.EmbedBatchSize > 0 {
+			batchSize = cfg.EmbedBatchSize
+		}
+		if cfg.EmbedMaxConcurrency > 0 {
+			concurrency = cfg.EmbedMaxConcurrency
+		}
+		if cfg.EmbedRetryPolicy != nil {
+			retry = *cfg.EmbedRetryPolicy
+		}
+	}
+
+	batches := batchDocuments(input.Documents, batchSize)
+	embeddings := make([][]*ai.Embedding, len(batches))
+	errs := make([]error, len(batches))
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, batch []*ai.Document) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			embeddings[i], errs[i] = embedBatchWithRetry(ctx, em, batch, retry)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var all []*ai.Embedding
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("googleai: embedding batch %d of %d: %w", i+1, len(batches), err)
+		}
+		all = append(all, embeddings[i]...)
+	}
+	return &ai.EmbedResponse{Embeddings: all}, nil
+}
+
+func batchDocuments(docs []*ai.Document, size int) [][]*ai.Document {
+	var batches [][]*ai.Document
+	for size < len(docs) {
+		batches = append(batches, docs[:size:size])
+		docs = docs[size:]
+	}
+	if len(docs) > 0 {
+		batches = append(batches, docs)
+	}
+	return batches
+}
+
+func embedBatchWithRetry(ctx context.Context, em *genai.EmbeddingModel, docs []*ai.Document, retry RetryPolicy) ([]*ai.Embedding, error) {
+	var lastErr error
+	for attempt := 0; attempt <= retry.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := retry.delay(attempt, lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		embs, err := embedBatch(ctx, em, docs)
+		if err == nil {
+			return embs, nil
+		}
+		lastErr = err
+		if !isRetryable(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("exceeded %d retries: %w", retry.MaxAttempts, lastErr)
+}
+
+func embedBatch(ctx context.Context, em *genai.EmbeddingModel, docs []*ai.Document) ([]*ai.Embedding, error) {
+	batch := em.NewBatch()
+	for _, d := range docs {
+		var parts []genai.Part
+		for _, p := range d.Content {
+			if p.IsText() {
+				parts = append(parts, genai.Text(p.Text))
+			}
+		}
+		batch.AddContent(parts...)
+	}
+
+	res, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	embs := make([]*ai.Embedding, len(res.Embeddings))
+	for i, e := range res.Embeddings {
+		embs[i] = &ai.Embedding{Embedding: e.Values}
+	}
+	return embs, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+// Task types accepted by [EmbedOptions.TaskType], matching the values the
+// text-embedding-004 family (and this plugin's "embedding-001") accept on
+// both the Gemini and Vertex AI endpoints.
+const (
+	TaskTypeRetrievalQuery     = "RETRIEVAL_QUERY"
+	TaskTypeRetrievalDocument  = "RETRIEVAL_DOCUMENT"
+	TaskTypeQuestionAnswering  = "QUESTION_ANSWERING"
+	TaskTypeSemanticSimilarity = "SEMANTIC_SIMILARITY"
+	TaskTypeClassification     = "CLASSIFICATION"
+)
+
+// EmbedOptions holds googleai-specific parameters for an embed call. Pass it
+// as the embedder-specific options via [ai.WithEmbedOptions]:
+//
+//	ai.Embed(ctx, embedder, ai.WithEmbedText("hello"),
+//		ai.WithEmbedOptions(&googleai.EmbedOptions{
+//			OutputDimensionality: 256,
+//			TaskType:             googleai.TaskTypeRetrievalDocument,
+//		}))
+type EmbedOptions struct {
+	// OutputDimensionality truncates the returned embedding to this many
+	// dimensions. Zero means use the model's default dimensionality.
+	OutputDimensionality int
+
+	// TaskType tells the model how the embedding will be used, which the
+	// model uses to produce a better vector for that use case. One of the
+	// TaskType constants above; empty means the model's default.
+	TaskType string
+}
@@ -0,0 +1,29 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+// ResetForTest clears the package's global init state so that multiple
+// tests in the same process can each call [Init] against their own
+// transcript or test server. It is exported only for use by this package's
+// external tests (the export_test.go pattern) and is not part of the
+// public API.
+func ResetForTest() {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.initted = false
+	state.client = nil
+	state.vertexClient = nil
+	state.cfg = nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"context"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+// generate translates an [ai.GenerateRequest] into a genai request, calls
+// the Gemini API, and translates the result back into genkit types. When cb
+// is non-nil the request is streamed and cb is called once per chunk.
+func generate(ctx context.Context, client *genai.Client, model string, input *ai.GenerateRequest, cb func(context.Context, *ai.GenerateResponseChunk) error) (*ai.GenerateResponse, error) {
+	gm := client.GenerativeModel(model)
+	configureModel(gm, input)
+
+	parts := toGenaiParts(lastMessage(input.Messages))
+	var history []*genai.Content
+	if n := len(input.Messages); n > 1 {
+		history = toGenaiContent(input.Messages[:n-1])
+	}
+
+	cs := gm.StartChat()
+	cs.History = history
+
+	if cb == nil {
+		resp, err := cs.SendMessage(ctx, parts...)
+		if err != nil {
+			return nil, err
+		}
+		if be := blockedError(resp); be != nil {
+			return nil, be
+		}
+		return translateResponse(resp, input)
+	}
+
+	var last *genai.GenerateContentResponse
+	iter := cs.SendMessageStream(ctx, parts...)
+	for {
+		chunk, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = chunk
+		if err := cb(ctx, translateChunk(chunk)); err != nil {
+			return nil, err
+		}
+	}
+	if be := blockedError(last); be != nil {
+		return nil, be
+	}
+	return translateResponse(last, input)
+}
+
+func lastMessage(msgs []*ai.Message) *ai.Message {
+	if len(msgs) == 0 {
+		return &ai.Message{}
+	}
+	return msgs[len(msgs)-1]
+}
+
+func configureModel(gm *genai.GenerativeModel, input *ai.GenerateRequest) {
+	switch c := input.Config.(type) {
+	case *ai.GenerationCommonConfig:
+		applyCommonConfig(gm, c)
+	case *GenerationConfig:
+		applyCommonConfig(gm, &c.GenerationCommonConfig)
+		if c.ResponseMIMEType != "" {
+			gm.ResponseMIMEType = c.ResponseMIMEType
+		}
+		if c.ResponseSchema != nil {
+			gm.ResponseSchema = schemaToGenai(c.ResponseSchema)
+		}
+		if len(c.SafetySettings) > 0 {
+			gm.SafetySettings = toGenaiSafetySettings(c.SafetySettings)
+		}
+		if c.SystemInstruction != "" {
+			gm.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(c.SystemInstruction)}}
+		}
+	}
+	// ai.WithOutputSchema (used by ai.GenerateTyped) sets Output.Schema
+	// instead of a googleai-specific Config, so providers that don't
+	// support structured output can ignore it safely.
+	if input.Output != nil && input.Output.Schema != nil {
+		gm.ResponseMIMEType = "application/json"
+		gm.ResponseSchema = schemaToGenai(input.Output.Schema)
+	}
+	for _, t := range input.Tools {
+		gm.Tools = append(gm.Tools, toGenaiTool(t))
+	}
+}
+
+func applyCommonConfig(gm *genai.GenerativeModel, gc *ai.GenerationCommonConfig) {
+	if gc == nil {
+		return
+	}
+	if gc.Temperature != 0 {
+		gm.SetTemperature(float32(gc.Temperature))
+	}
+	if gc.TopK != 0 {
+		gm.SetTopK(int32(gc.TopK))
+	}
+	if gc.TopP != 0 {
+		gm.SetTopP(float32(gc.TopP))
+	}
+	if gc.MaxOutputTokens != 0 {
+		gm.SetMaxOutputTokens(int32(gc.MaxOutputTokens))
+	}
+}
+
+func toGenaiContent(msgs []*ai.Message) []*genai.Content {
+	var out []*genai.Content
+	for _, m := range msgs {
+		out = append(out, &genai.Content{
+			Role:  toGenaiRole(m.Role),
+			Parts: toGenaiParts(m),
+		})
+	}
+	return out
+}
+
+func toGenaiRole(r ai.Role) string {
+	if r == ai.RoleModel {
+		return "model"
+	}
+	return "user"
+}
+
+func toGenaiParts(m *ai.Message) []genai.Part {
+	var parts []genai.Part
+	for _, p := range m.Content {
+		if p.IsText() {
+			parts = append(parts, genai.Text(p.Text))
+		}
+	}
+	return parts
+}
+
+func toGenaiTool(t *ai.ToolDefinition) *genai.Tool {
+	return &genai.Tool{
+		FunctionDeclarations: []*genai.FunctionDeclaration{{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  schemaToGenai(t.InputSchema),
+		}},
+	}
+}
+
+func translateChunk(resp *genai.GenerateContentResponse) *ai.GenerateResponseChunk {
+	chunk := &ai.GenerateResponseChunk{}
+	if len(resp.Candidates) > 0 {
+		for _, p := range resp.Candidates[0].Content.Parts {
+			if t, ok := p.(genai.Text); ok {
+				chunk.Content = append(chunk.Content, ai.NewTextPart(string(t)))
+			}
+		}
+	}
+	// A blocked chunk carries no text, so attach the block metadata to the
+	// chunk itself rather than letting it stream through as silent empty
+	// text; generate still returns the same [BlockedError] once the stream
+	// ends.
+	if be := blockedError(resp); be != nil {
+		chunk.Custom = be
+	}
+	return chunk
+}
+
+func translateResponse(resp *genai.GenerateContentResponse, input *ai.GenerateRequest) (*ai.GenerateResponse, error) {
+	r := &ai.GenerateResponse{Request: input}
+	if resp == nil {
+		return r, nil
+	}
+	for _, c := range resp.Candidates {
+		r.Candidates = append(r.Candidates, translateCandidate(c))
+	}
+	if u := resp.UsageMetadata; u != nil {
+		r.Usage = &ai.GenerationUsage{
+			InputTokens:  int(u.PromptTokenCount),
+			OutputTokens: int(u.CandidatesTokenCount),
+			TotalTokens:  int(u.TotalTokenCount),
+		}
+	}
+	return r, nil
+}
+
+func translateCandidate(c *genai.Candidate) *ai.Candidate {
+	m := &ai.Message{Role: ai.RoleModel}
+	if c.Content != nil {
+		for _, p := range c.Content.Parts {
+			if t, ok := p.(genai.Text); ok {
+				m.Content = append(m.Content, ai.NewTextPart(string(t)))
+			}
+		}
+	}
+	return &ai.Candidate{Index: int(c.Index), Message: m, FinishReason: translateFinishReason(c.FinishReason)}
+}
+
+func translateFinishReason(fr genai.FinishReason) ai.FinishReason {
+	switch fr {
+	case genai.FinishReasonStop:
+		return ai.FinishReasonStop
+	case genai.FinishReasonMaxTokens:
+		return ai.FinishReasonLength
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation:
+		return ai.FinishReasonBlocked
+	default:
+		return ai.FinishReasonOther
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"github.com/firebase/genkit/go/ai"
+	"github.com/invopop/jsonschema"
+)
+
+// GenerationConfig is the googleai-specific request configuration set by
+// [WithResponseSchema], [WithSafetySettings], and [WithSystemInstruction].
+// It embeds [ai.GenerationCommonConfig] so the provider-agnostic options
+// (ai.WithCandidates, and so on) keep working alongside it.
+type GenerationConfig struct {
+	ai.GenerationCommonConfig
+
+	// ResponseMIMEType, when non-empty, is sent as Gemini's
+	// responseMimeType, e.g. "application/json".
+	ResponseMIMEType string
+	// ResponseSchema constrains the response to this JSON schema. Set via
+	// [WithResponseSchema].
+	ResponseSchema *jsonschema.Schema
+
+	// SafetySettings overrides Gemini's default content-safety thresholds.
+	// Set via [WithSafetySettings].
+	SafetySettings []SafetySetting
+	// SystemInstruction sets Gemini's systemInstruction field. Set via
+	// [WithSystemInstruction].
+	SystemInstruction string
+}
+
+// getOrCreateConfig returns req.Config as a *GenerationConfig, converting an
+// existing *ai.GenerationCommonConfig in place and installing a fresh
+// *GenerationConfig if req.Config is unset. Each googleai With* generate
+// option calls this so they can be combined freely in any order.
+func getOrCreateConfig(req *ai.GenerateRequest) *GenerationConfig {
+	switch c := req.Config.(type) {
+	case *GenerationConfig:
+		return c
+	case *ai.GenerationCommonConfig:
+		gc := &GenerationConfig{}
+		if c != nil {
+			gc.GenerationCommonConfig = *c
+		}
+		req.Config = gc
+		return gc
+	default:
+		gc := &GenerationConfig{}
+		req.Config = gc
+		return gc
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package googleai provides a genkit plugin for the Google AI Gemini APIs.
+package googleai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	vertexai "cloud.google.com/go/vertexai/genai"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/internal"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+const provider = "googleai"
+
+// Config is the configuration for the plugin.
+type Config struct {
+	// APIKey is the API key used to authenticate requests with the Gemini API.
+	// Required unless UseVertex is set.
+	APIKey string
+
+	// ClientOptions are additional options passed to the underlying genai
+	// client, e.g. option.WithHTTPClient to install a custom round tripper.
+	ClientOptions []option.ClientOption
+
+	// EmbedBatchSize caps how many documents are sent in a single
+	// BatchEmbedContents call. It defaults to [defaultEmbedBatchSize].
+	EmbedBatchSize int
+
+	// EmbedMaxConcurrency caps how many embed batches are in flight at
+	// once. It defaults to 1 (batches are sent one at a time).
+	EmbedMaxConcurrency int
+
+	// EmbedRetryPolicy controls retries of embed batches that fail with a
+	// 429 or 5xx response. It defaults to [defaultRetryPolicy].
+	EmbedRetryPolicy *RetryPolicy
+
+	// UseVertex switches the plugin from the Gemini API (authenticated with
+	// APIKey) to the Vertex AI Gemini endpoint (authenticated with
+	// Application Default Credentials), while keeping the same
+	// [Model] / [Embedder] surface. Project and Location are required when
+	// this is set, and APIKey is ignored.
+	UseVertex bool
+
+	// Project is the GCP project to call Vertex AI in. Required when
+	// UseVertex is set.
+	Project string
+
+	// Location is the Vertex AI region to call, e.g. "us-central1".
+	// Required when UseVertex is set.
+	Location string
+}
+
+var state struct {
+	mu           sync.Mutex
+	initted      bool
+	client       *genai.Client
+	vertexClient *vertexai.Client
+	cfg          *Config
+}
+
+// Init initializes the plugin and all known models and embedders.
+// After calling Init, you may call [Model] and [Embedder] to create
+// references to the models and embedders that this plugin supports.
+func Init(ctx context.Context, cfg *Config) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.initted {
+		return errors.New("googleai.Init already called")
+	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	if cfg.UseVertex {
+		if cfg.Project == "" || cfg.Location == "" {
+			return errors.New("googleai.Init: Project and Location are required when UseVertex is set")
+		}
+		client, err := vertexai.NewClient(ctx, cfg.Project, cfg.Location, cfg.ClientOptions...)
+		if err != nil {
+			return fmt.Errorf("googleai.Init: %w", err)
+		}
+		state.vertexClient = client
+	} else {
+		if cfg.APIKey == "" {
+			return errors.New("googleai.Init: APIKey is required")
+		}
+		opts := append([]option.ClientOption{
+			option.WithAPIKey(cfg.APIKey),
+			genai.WithClientInfo("genkit-go", internal.Version),
+		}, cfg.ClientOptions...)
+
+		client, err := genai.NewClient(ctx, opts...)
+		if err != nil {
+			return fmt.Errorf("googleai.Init: %w", err)
+		}
+		state.client = client
+	}
+	state.cfg = cfg
+	state.initted = true
+
+	for name, caps := range knownModels {
+		defineModel(name, caps)
+	}
+	for _, name := range knownEmbedders {
+		defineEmbedder(name)
+	}
+	return nil
+}
+
+// Model returns the [ai.Model] with the given name.
+// It returns nil if the model was not defined.
+func Model(name string) ai.Model {
+	return ai.LookupModel(provider, name)
+}
+
+// DefineModel defines an unknown model with the given name.
+// Use [Model] to use an already known model.
+func DefineModel(name string, caps *ai.ModelCapabilities) ai.Model {
+	var mc ai.ModelCapabilities
+	if caps != nil {
+		mc = *caps
+	} else if c, ok := knownModels[name]; ok {
+		mc = c
+	} else {
+		panic(fmt.Sprintf("googleai.DefineModel: called with unknown model %q and nil ModelCapabilities", name))
+	}
+	return defineModel(name, mc)
+}
+
+func defineModel(name string, caps ai.ModelCapabilities) ai.Model {
+	meta := &ai.ModelMetadata{
+		Label:    "Google AI - " + name,
+		Supports: caps,
+	}
+	return ai.DefineModel(provider, name, meta, func(ctx context.Context, input *ai.GenerateRequest, cb func(context.Context, *ai.GenerateResponseChunk) error) (*ai.GenerateResponse, error) {
+		if state.cfg != nil && state.cfg.UseVertex {
+			return generateVertex(ctx, state.vertexClient, name, input, cb)
+		}
+		return generate(ctx, state.client, name, input, cb)
+	})
+}
+
+// Embedder returns the [ai.Embedder] with the given name.
+// It returns nil if the embedder was not defined.
+func Embedder(name string) ai.Embedder {
+	return ai.LookupEmbedder(provider, name)
+}
+
+// DefineEmbedder defines an embedder with the given name.
+func DefineEmbedder(name string) ai.Embedder {
+	return defineEmbedder(name)
+}
+
+func defineEmbedder(name string) ai.Embedder {
+	return ai.DefineEmbedder(provider, name, func(ctx context.Context, input *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+		if state.cfg != nil && state.cfg.UseVertex {
+			return embedVertex(ctx, state.vertexClient, state.cfg, name, input)
+		}
+		return embed(ctx, state.client, state.cfg, name, input)
+	})
+}
+
+var knownEmbedders = []string{
+	"embedding-001",
+}
+
+var knownModels = map[string]ai.ModelCapabilities{
+	"gemini-1.0-pro": {
+		Multiturn:  true,
+		Tools:      true,
+		SystemRole: false,
+		Media:      false,
+	},
+	"gemini-1.5-pro": {
+		Multiturn:  true,
+		Tools:      true,
+		SystemRole: true,
+		Media:      true,
+	},
+	"gemini-1.5-flash": {
+		Multiturn:  true,
+		Tools:      true,
+		SystemRole: true,
+		Media:      true,
+	},
+}
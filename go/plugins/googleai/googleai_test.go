@@ -21,6 +21,8 @@ import (
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -31,33 +33,105 @@ import (
 	"google.golang.org/api/option"
 )
 
-// The tests here only work with an API key set to a valid value.
-var apiKey = flag.String("key", "", "Gemini API key")
-
-var header = flag.Bool("header", false, "run test for x-goog-client-api header")
+// The tests here are meant to run against recorded HTTP transcripts under
+// testdata/, so that once a transcript exists `go test ./...` needs neither
+// a key nor network access. No transcripts are checked in yet, though: until
+// someone with API access runs this file with -key (or GEMINI_API_KEY,
+// matching generative-ai-go's convention) to record testdata/TestLive.yaml,
+// and with GOOGLE_CLOUD_PROJECT and GOOGLE_CLOUD_LOCATION set to record
+// testdata/TestVertexLive.yaml, TestLive and TestVertexLive (and everything
+// runLiveSuite exercises) just skip, uncovered, in `go test ./...`.
+var apiKey = flag.String("key", "", "Gemini API key; re-records testdata transcripts when set")
 
 // We can't test the DefineAll functions along with the other tests because
 // we get duplicate definitions of models.
 var testAll = flag.Bool("all", false, "test DefineAllXXX functions")
 
-func TestLive(t *testing.T) {
-	if *apiKey == "" {
-		t.Skipf("no -key provided")
+func liveKey() string {
+	if *apiKey != "" {
+		return *apiKey
 	}
+	return os.Getenv("GEMINI_API_KEY")
+}
+
+func TestLive(t *testing.T) {
 	if *testAll {
 		t.Skip("-all provided")
 	}
+	testdataPath := filepath.Join("testdata", "TestLive.yaml")
+
+	key := liveKey()
+	mode := googleai.ModeReplay
+	if key != "" {
+		mode = googleai.ModeRecord
+	} else if _, err := os.Stat(testdataPath); err != nil {
+		t.Skipf("no -key/GEMINI_API_KEY and no recorded transcript at %s", testdataPath)
+	} else {
+		key = "test-api-key" // unused in replay mode, but Config requires a value
+	}
+
+	recorder, err := googleai.WithRecorder(testdataPath, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	ctx := context.Background()
-	err := googleai.Init(ctx, &googleai.Config{APIKey: *apiKey})
+	googleai.ResetForTest()
+	err = googleai.Init(ctx, &googleai.Config{APIKey: key, ClientOptions: []option.ClientOption{recorder}})
 	if err != nil {
 		t.Fatal(err)
 	}
 	embedder := googleai.Embedder("embedding-001")
 	model := googleai.Model("gemini-1.0-pro")
+	runLiveSuite(t, ctx, embedder, model, "gablorken")
+}
+
+// TestVertexLive runs the same checks as TestLive against the Vertex AI
+// transport added by Config.UseVertex, so both dialects are exercised by the
+// same test matrix. It needs GOOGLE_CLOUD_PROJECT and GOOGLE_CLOUD_LOCATION
+// to re-record; otherwise it replays testdata/TestVertexLive.yaml.
+func TestVertexLive(t *testing.T) {
+	if *testAll {
+		t.Skip("-all provided")
+	}
+	testdataPath := filepath.Join("testdata", "TestVertexLive.yaml")
+
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	location := os.Getenv("GOOGLE_CLOUD_LOCATION")
+	mode := googleai.ModeReplay
+	if project != "" && location != "" {
+		mode = googleai.ModeRecord
+	} else if _, err := os.Stat(testdataPath); err != nil {
+		t.Skipf("no GOOGLE_CLOUD_PROJECT/GOOGLE_CLOUD_LOCATION and no recorded transcript at %s", testdataPath)
+	} else {
+		project, location = "test-project", "us-central1" // unused in replay mode
+	}
+
+	recorder, err := googleai.WithRecorder(testdataPath, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	googleai.ResetForTest()
+	err = googleai.Init(ctx, &googleai.Config{
+		UseVertex:     true,
+		Project:       project,
+		Location:      location,
+		ClientOptions: []option.ClientOption{recorder},
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	gablorkenTool := ai.DefineTool("gablorken", "use when need to calculate a gablorken",
+	embedder := googleai.Embedder("embedding-001")
+	model := googleai.Model("gemini-1.0-pro")
+	runLiveSuite(t, ctx, embedder, model, "gablorken-vertex")
+}
+
+// runLiveSuite runs the generate/streaming/tool/embed checks shared by
+// TestLive and TestVertexLive against an already-initialized transport.
+func runLiveSuite(t *testing.T, ctx context.Context, embedder ai.Embedder, model ai.Model, toolName string) {
+	gablorkenTool := ai.DefineTool(toolName, "use when need to calculate a gablorken",
 		func(ctx context.Context, input struct {
 			Value float64
 			Over  float64
@@ -84,6 +158,21 @@ func TestLive(t *testing.T) {
 			t.Errorf("embedding vector not unit length: %f", normSquared)
 		}
 	})
+	t.Run("embedder-dimensionality", func(t *testing.T) {
+		const wantDim = 256
+		res, err := ai.Embed(ctx, embedder,
+			ai.WithEmbedText("yellow banana"),
+			ai.WithEmbedOptions(&googleai.EmbedOptions{
+				OutputDimensionality: wantDim,
+				TaskType:             googleai.TaskTypeRetrievalDocument,
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(res.Embeddings[0].Embedding); got != wantDim {
+			t.Errorf("got embedding of length %d, want %d", got, wantDim)
+		}
+	})
 	t.Run("generate", func(t *testing.T) {
 		resp, err := ai.Generate(ctx, model, ai.WithCandidates(1), ai.WithTextPrompt("Which country was Napoleon the emperor of?"))
 		if err != nil {
@@ -134,6 +223,45 @@ func TestLive(t *testing.T) {
 			t.Errorf("Empty usage stats %#v", *final.Usage)
 		}
 	})
+	t.Run("generate-typed", func(t *testing.T) {
+		type ingredient struct {
+			Name     string `json:"name"`
+			Optional bool   `json:"optional"`
+		}
+		type recipe struct {
+			Title       string       `json:"title"`
+			Difficulty  string       `json:"difficulty" jsonschema:"enum=easy,enum=medium,enum=hard"`
+			Ingredients []ingredient `json:"ingredients"`
+		}
+		r, err := ai.GenerateTyped[recipe](ctx, model,
+			ai.WithTextPrompt("Give me a simple recipe for scrambled eggs, as JSON."))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Title == "" {
+			t.Error("got empty title")
+		}
+		if len(r.Ingredients) == 0 {
+			t.Error("got no ingredients")
+		}
+	})
+	t.Run("system-instruction-and-safety", func(t *testing.T) {
+		resp, err := ai.Generate(ctx, model,
+			ai.WithCandidates(1),
+			ai.WithTextPrompt("Which country was Napoleon the emperor of?"),
+			googleai.WithSystemInstruction("Answer in French."),
+			googleai.WithSafetySettings([]googleai.SafetySetting{
+				{Category: googleai.HarmCategoryDangerousContent, Threshold: googleai.HarmBlockOnlyHigh},
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := resp.Candidates[0].Message.Content[0].Text
+		const want = "France"
+		if !strings.Contains(out, want) {
+			t.Errorf("got %q, expecting it to contain %q", out, want)
+		}
+	})
 	t.Run("tool", func(t *testing.T) {
 		resp, err := ai.Generate(ctx, model,
 			ai.WithCandidates(1),
@@ -153,9 +281,6 @@ func TestLive(t *testing.T) {
 }
 
 func TestHeader(t *testing.T) {
-	if !*header {
-		t.Skip("skipped; to run, pass -header and don't run the live test")
-	}
 	ctx := context.Background()
 	var header http.Header
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -165,6 +290,7 @@ func TestHeader(t *testing.T) {
 	defer server.Close()
 
 	opts := []option.ClientOption{option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL)}
+	googleai.ResetForTest()
 	if err := googleai.Init(ctx, &googleai.Config{APIKey: "x", ClientOptions: opts}); err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,242 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"google.golang.org/api/option"
+)
+
+// Mode selects how [WithRecorder] behaves.
+type Mode int
+
+const (
+	// ModeReplay serves responses from the transcript file and fails the
+	// request if no matching exchange is found.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the real API and appends the
+	// request/response pair to the transcript file.
+	ModeRecord
+	// ModeAuto replays if the transcript file exists and records otherwise.
+	ModeAuto
+)
+
+// scrubbedHeaders are stripped from recorded requests so transcripts never
+// contain credentials.
+var scrubbedHeaders = []string{"x-goog-api-key", "x-goog-api-client", "authorization"}
+
+// exchange is one recorded request/response pair. The transcript file is a
+// sequence of JSON-encoded exchanges, one per line.
+type exchange struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"` // canonicalized JSON, or raw bytes for non-JSON bodies
+
+	Status     int         `json:"status"`
+	RespHeader http.Header `json:"respHeader"`
+	RespBody   string      `json:"respBody"`
+}
+
+// WithRecorder returns an [option.ClientOption] that routes the client's
+// HTTP traffic through a record/replay round tripper backed by path.
+//
+// In ModeRecord every request is sent to the real API and the
+// request/response pair is appended to path, with API keys and other
+// credentials scrubbed from the recorded headers. In ModeReplay requests are
+// served from path without making any network calls; this lets
+// `go test ./...` run offline and without an API key. ModeAuto replays when
+// path already exists and records otherwise, which is convenient when
+// re-recording a transcript: delete the file and run the test once with a
+// real key to capture a fresh one.
+func WithRecorder(path string, mode Mode) (option.ClientOption, error) {
+	rt, err := newRecordReplayTransport(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	return option.WithHTTPClient(&http.Client{Transport: rt}), nil
+}
+
+type recordReplayTransport struct {
+	path      string
+	recording bool
+
+	mu     sync.Mutex // guards next and, in recording mode, appends to path
+	replay []exchange // consumed in order as requests come in
+	next   int
+}
+
+func newRecordReplayTransport(path string, mode Mode) (*recordReplayTransport, error) {
+	t := &recordReplayTransport{path: path}
+	switch mode {
+	case ModeRecord:
+		t.recording = true
+	case ModeReplay:
+		t.recording = false
+	case ModeAuto:
+		_, err := os.Stat(path)
+		t.recording = os.IsNotExist(err)
+	default:
+		return nil, fmt.Errorf("googleai.WithRecorder: unknown mode %v", mode)
+	}
+	if !t.recording {
+		exs, err := readTranscript(path)
+		if err != nil {
+			return nil, fmt.Errorf("googleai.WithRecorder: %w", err)
+		}
+		t.replay = exs
+	}
+	return t, nil
+}
+
+func (t *recordReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.recording {
+		return t.roundTripRecord(req)
+	}
+	return t.roundTripReplay(req)
+}
+
+func (t *recordReplayTransport) roundTripRecord(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	ex := exchange{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Header:     scrubHeader(req.Header),
+		Body:       canonicalizeJSON(reqBody),
+		Status:     resp.StatusCode,
+		RespHeader: resp.Header.Clone(),
+		RespBody:   canonicalizeJSON(respBody),
+	}
+	t.mu.Lock()
+	err = appendExchange(t.path, ex)
+	t.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("googleai: recording transcript: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *recordReplayTransport) roundTripReplay(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	body := canonicalizeJSON(reqBody)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := t.next; i < len(t.replay); i++ {
+		ex := t.replay[i]
+		if ex.Method == req.Method && ex.URL == req.URL.String() && ex.Body == body {
+			t.next = i + 1
+			return &http.Response{
+				StatusCode: ex.Status,
+				Header:     ex.RespHeader,
+				Body:       io.NopCloser(bytes.NewReader([]byte(ex.RespBody))),
+				Request:    req,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("googleai: no recorded response for %s %s in %s", req.Method, req.URL, t.path)
+}
+
+func scrubHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range scrubbedHeaders {
+		out.Del(k)
+	}
+	return out
+}
+
+// canonicalizeJSON compacts JSON bodies so that protobuf-generated payloads
+// with randomized whitespace still match on replay. Field order is not
+// normalized. Bodies that are not valid JSON are left untouched.
+func canonicalizeJSON(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return string(b)
+	}
+	return buf.String()
+}
+
+func readTranscript(path string) ([]exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var exs []exchange
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var ex exchange
+		if err := json.Unmarshal(line, &ex); err != nil {
+			return nil, err
+		}
+		exs = append(exs, ex)
+	}
+	return exs, scanner.Err()
+}
+
+func appendExchange(path string, ex exchange) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	return enc.Encode(ex)
+}
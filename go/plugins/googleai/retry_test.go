@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second}, // would be 8s uncapped; MaxDelay caps it
+	}
+	for _, c := range cases {
+		if got := p.delay(c.attempt, nil); got != c.want {
+			t.Errorf("delay(%d, nil) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayHonorsRetryAfter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	err := &googleapi.Error{
+		Code:   http.StatusTooManyRequests,
+		Header: http.Header{"Retry-After": []string{"10"}},
+	}
+	// Retry-After should win over the 1s exponential backoff for this
+	// attempt, and is not capped at MaxDelay even though 10s exceeds it.
+	if got, want := p.delay(1, err), 10*time.Second; got != want {
+		t.Errorf("delay(1, err) = %v, want %v", got, want)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		wantOK  bool
+		wantDur time.Duration
+	}{
+		{"no Retry-After header", &googleapi.Error{Header: http.Header{}}, false, 0},
+		{"not a googleapi.Error", errors.New("boom"), false, 0},
+		{"Retry-After present", &googleapi.Error{Header: http.Header{"Retry-After": []string{"3"}}}, true, 3 * time.Second},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gotDur, gotOK := retryAfter(c.err)
+			if gotOK != c.wantOK || gotDur != c.wantDur {
+				t.Errorf("retryAfter(%v) = (%v, %v), want (%v, %v)", c.err, gotDur, gotOK, c.wantDur, c.wantOK)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"429", &googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{"500", &googleapi.Error{Code: http.StatusInternalServerError}, true},
+		{"503", &googleapi.Error{Code: http.StatusServiceUnavailable}, true},
+		{"400", &googleapi.Error{Code: http.StatusBadRequest}, false},
+		{"not a googleapi.Error", errors.New("boom"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetryable(c.err); got != c.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"fmt"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Harm categories accepted by [SafetySetting.Category].
+const (
+	HarmCategoryHarassment       = "HARM_CATEGORY_HARASSMENT"
+	HarmCategoryHateSpeech       = "HARM_CATEGORY_HATE_SPEECH"
+	HarmCategorySexuallyExplicit = "HARM_CATEGORY_SEXUALLY_EXPLICIT"
+	HarmCategoryDangerousContent = "HARM_CATEGORY_DANGEROUS_CONTENT"
+)
+
+// Block thresholds accepted by [SafetySetting.Threshold], from least to
+// most permissive.
+const (
+	HarmBlockLowAndAbove    = "BLOCK_LOW_AND_ABOVE"
+	HarmBlockMediumAndAbove = "BLOCK_MEDIUM_AND_ABOVE"
+	HarmBlockOnlyHigh       = "BLOCK_ONLY_HIGH"
+	HarmBlockNone           = "BLOCK_NONE"
+)
+
+// SafetySetting overrides Gemini's default block threshold for one harm
+// category. Pass a slice of these to [WithSafetySettings].
+type SafetySetting struct {
+	// Category is the harm category this setting applies to, one of the
+	// HarmCategory constants above.
+	Category string
+	// Threshold is the probability level at which content in Category is
+	// blocked, one of the HarmBlock constants above.
+	Threshold string
+}
+
+// WithSafetySettings overrides Gemini's default content-safety thresholds
+// for a generate call. Categories not mentioned in settings keep Gemini's
+// default threshold.
+func WithSafetySettings(settings []SafetySetting) ai.GenerateOption {
+	return func(req *ai.GenerateRequest) error {
+		gc := getOrCreateConfig(req)
+		gc.SafetySettings = settings
+		return nil
+	}
+}
+
+// WithSystemInstruction sets Gemini's systemInstruction field for a generate
+// call, steering the model's behavior separately from the conversation
+// history.
+func WithSystemInstruction(instruction string) ai.GenerateOption {
+	return func(req *ai.GenerateRequest) error {
+		gc := getOrCreateConfig(req)
+		gc.SystemInstruction = instruction
+		return nil
+	}
+}
+
+// SafetyRating is Gemini's assessed probability that content falls into one
+// harm category, surfaced on a [BlockedError].
+type SafetyRating struct {
+	// Category is the harm category being rated, one of the HarmCategory
+	// constants above.
+	Category string
+	// Probability is Gemini's assessed likelihood that the content falls
+	// into Category, e.g. "NEGLIGIBLE", "LOW", "MEDIUM", "HIGH".
+	Probability string
+	// Blocked reports whether this rating is what caused the block.
+	Blocked bool
+}
+
+// BlockedError is returned by a generate call whose response was withheld
+// by Gemini's safety filtering, either for the prompt itself (via
+// promptFeedback) or for a candidate (via a safety finish reason). Callers
+// can use errors.As to distinguish a content-policy block from a transport
+// failure.
+type BlockedError struct {
+	// BlockReason is Gemini's promptFeedback.blockReason, e.g. "SAFETY" or
+	// "OTHER". Empty when the prompt itself wasn't blocked.
+	BlockReason string
+	// BlockReasonMessage is Gemini's human-readable elaboration of
+	// BlockReason, when provided.
+	BlockReasonMessage string
+	// SafetyRatings are the per-category probabilities Gemini assigned to
+	// the blocked prompt or candidate.
+	SafetyRatings []SafetyRating
+	// FinishReason is the blocked candidate's finishReason, e.g. "SAFETY"
+	// or "RECITATION". Empty when the whole prompt was blocked before any
+	// candidate was generated.
+	FinishReason string
+}
+
+func (e *BlockedError) Error() string {
+	if e.BlockReason != "" {
+		if e.BlockReasonMessage != "" {
+			return fmt.Sprintf("googleai: prompt blocked: %s: %s", e.BlockReason, e.BlockReasonMessage)
+		}
+		return fmt.Sprintf("googleai: prompt blocked: %s", e.BlockReason)
+	}
+	return fmt.Sprintf("googleai: response blocked: %s", e.FinishReason)
+}
+
+// toGenaiSafetySettings translates our plugin-level safety settings into
+// genai's dialect.
+func toGenaiSafetySettings(settings []SafetySetting) []*genai.SafetySetting {
+	if len(settings) == 0 {
+		return nil
+	}
+	out := make([]*genai.SafetySetting, len(settings))
+	for i, s := range settings {
+		out[i] = &genai.SafetySetting{
+			Category:  genaiHarmCategory(s.Category),
+			Threshold: genaiHarmBlockThreshold(s.Threshold),
+		}
+	}
+	return out
+}
+
+func genaiHarmCategory(c string) genai.HarmCategory {
+	switch c {
+	case HarmCategoryHarassment:
+		return genai.HarmCategoryHarassment
+	case HarmCategoryHateSpeech:
+		return genai.HarmCategoryHateSpeech
+	case HarmCategorySexuallyExplicit:
+		return genai.HarmCategorySexuallyExplicit
+	case HarmCategoryDangerousContent:
+		return genai.HarmCategoryDangerousContent
+	default:
+		return genai.HarmCategoryUnspecified
+	}
+}
+
+func genaiHarmBlockThreshold(t string) genai.HarmBlockThreshold {
+	switch t {
+	case HarmBlockLowAndAbove:
+		return genai.HarmBlockLowAndAbove
+	case HarmBlockMediumAndAbove:
+		return genai.HarmBlockMediumAndAbove
+	case HarmBlockOnlyHigh:
+		return genai.HarmBlockOnlyHigh
+	case HarmBlockNone:
+		return genai.HarmBlockNone
+	default:
+		return genai.HarmBlockUnspecified
+	}
+}
+
+// blockedError reports whether resp was blocked, either at the prompt level
+// or because every candidate was cut short by safety filtering, and if so
+// returns the [BlockedError] to surface to the caller, carrying the first
+// candidate's ratings and finish reason. It returns nil for an unblocked
+// response.
+func blockedError(resp *genai.GenerateContentResponse) *BlockedError {
+	if resp == nil {
+		return nil
+	}
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != genai.BlockReasonUnspecified {
+		return &BlockedError{
+			BlockReason:        fb.BlockReason.String(),
+			BlockReasonMessage: fb.BlockReasonMessage,
+			SafetyRatings:      toSafetyRatings(fb.SafetyRatings),
+		}
+	}
+	if len(resp.Candidates) == 0 {
+		return nil
+	}
+	// Only treat the response as blocked if every candidate was cut short
+	// by safety filtering; a partial block still leaves the caller a
+	// usable candidate, so it's surfaced via that candidate's
+	// ai.FinishReasonBlocked rather than discarding the whole response.
+	for _, c := range resp.Candidates {
+		switch c.FinishReason {
+		case genai.FinishReasonSafety, genai.FinishReasonRecitation:
+		default:
+			return nil
+		}
+	}
+	c := resp.Candidates[0]
+	return &BlockedError{
+		SafetyRatings: toSafetyRatings(c.SafetyRatings),
+		FinishReason:  c.FinishReason.String(),
+	}
+}
+
+func toSafetyRatings(ratings []*genai.SafetyRating) []SafetyRating {
+	if len(ratings) == 0 {
+		return nil
+	}
+	out := make([]SafetyRating, len(ratings))
+	for i, r := range ratings {
+		out[i] = SafetyRating{
+			Category:    r.Category.String(),
+			Probability: r.Probability.String(),
+			Blocked:     r.Blocked,
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestBlockedErrorPromptLevel(t *testing.T) {
+	resp := &genai.GenerateContentResponse{
+		PromptFeedback: &genai.PromptFeedback{
+			BlockReason:        genai.BlockReasonSafety,
+			BlockReasonMessage: "prompt contains unsafe content",
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryDangerousContent, Probability: genai.HarmProbabilityHigh, Blocked: true},
+			},
+		},
+	}
+
+	be := blockedError(resp)
+	if be == nil {
+		t.Fatal("got nil, want a *BlockedError")
+	}
+	if be.BlockReason != "SAFETY" {
+		t.Errorf("BlockReason = %q, want %q", be.BlockReason, "SAFETY")
+	}
+	if len(be.SafetyRatings) != 1 || !be.SafetyRatings[0].Blocked {
+		t.Errorf("got SafetyRatings %#v, want one blocked rating", be.SafetyRatings)
+	}
+}
+
+func TestBlockedErrorCandidateLevel(t *testing.T) {
+	resp := &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{FinishReason: genai.FinishReasonSafety},
+		},
+	}
+
+	be := blockedError(resp)
+	if be == nil {
+		t.Fatal("got nil, want a *BlockedError")
+	}
+	if be.BlockReason != "" {
+		t.Errorf("BlockReason = %q, want empty for a candidate-level block", be.BlockReason)
+	}
+	if be.FinishReason != "SAFETY" {
+		t.Errorf("FinishReason = %q, want %q", be.FinishReason, "SAFETY")
+	}
+}
+
+func TestBlockedErrorPartiallyBlocked(t *testing.T) {
+	resp := &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{FinishReason: genai.FinishReasonStop},
+			{FinishReason: genai.FinishReasonSafety},
+		},
+	}
+	if be := blockedError(resp); be != nil {
+		t.Errorf("got %#v, want nil when at least one candidate wasn't blocked", be)
+	}
+}
+
+func TestBlockedErrorUnblocked(t *testing.T) {
+	resp := &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{FinishReason: genai.FinishReasonStop},
+		},
+	}
+	if be := blockedError(resp); be != nil {
+		t.Errorf("got %#v, want nil for an unblocked response", be)
+	}
+}
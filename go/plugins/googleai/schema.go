@@ -0,0 +1,106 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/invopop/jsonschema"
+)
+
+// WithResponseSchema constrains a generate call to return JSON matching
+// schema, by setting Gemini's responseMimeType to "application/json" and
+// passing schema along as responseSchema. Use [ai.GenerateTyped] if you want
+// the schema derived from a Go type and the response unmarshaled for you.
+func WithResponseSchema(schema *jsonschema.Schema) ai.GenerateOption {
+	return func(req *ai.GenerateRequest) error {
+		gc := getOrCreateConfig(req)
+		gc.ResponseMIMEType = "application/json"
+		gc.ResponseSchema = schema
+		return nil
+	}
+}
+
+// schemaToGenai translates a subset of JSON Schema (object, array, string,
+// number, integer, boolean, enum, and named $ref/$defs) into Gemini's schema
+// dialect, which is what responseSchema and function-calling parameters both
+// expect. jsonschema.Reflect emits struct types, by default including the
+// root type itself, as "#/$defs/Name" refs, so refs are resolved against the
+// root schema's Definitions before translating.
+func schemaToGenai(s *jsonschema.Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	return schemaToGenaiDefs(s, s.Definitions)
+}
+
+func schemaToGenaiDefs(s *jsonschema.Schema, defs jsonschema.Definitions) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		return schemaToGenaiDefs(resolveRef(defs, s.Ref), defs)
+	}
+
+	out := &genai.Schema{
+		Description: s.Description,
+		Nullable:    false,
+	}
+
+	switch {
+	case len(s.Enum) > 0:
+		out.Type = genai.TypeString
+		for _, e := range s.Enum {
+			if str, ok := e.(string); ok {
+				out.Enum = append(out.Enum, str)
+			}
+		}
+	case s.Type == "object" || (s.Type == "" && s.Properties != nil):
+		out.Type = genai.TypeObject
+		out.Properties = map[string]*genai.Schema{}
+		for pair := s.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			out.Properties[pair.Key] = schemaToGenaiDefs(pair.Value, defs)
+		}
+		out.Required = s.Required
+	case s.Type == "array":
+		out.Type = genai.TypeArray
+		out.Items = schemaToGenaiDefs(s.Items, defs)
+	case s.Type == "integer":
+		out.Type = genai.TypeInteger
+	case s.Type == "number":
+		out.Type = genai.TypeNumber
+	case s.Type == "boolean":
+		out.Type = genai.TypeBoolean
+	default:
+		out.Type = genai.TypeString
+	}
+	return out
+}
+
+// resolveRef looks up a "#/$defs/Name" (or legacy "#/definitions/Name") ref
+// in defs. It returns an empty schema, rather than nil, for a ref that can't
+// be resolved, which the translators turn into a plain string schema.
+func resolveRef(defs jsonschema.Definitions, ref string) *jsonschema.Schema {
+	name := ref
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		name = ref[i+1:]
+	}
+	if def, ok := defs[name]; ok {
+		return def
+	}
+	return &jsonschema.Schema{}
+}
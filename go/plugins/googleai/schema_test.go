@@ -0,0 +1,53 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/invopop/jsonschema"
+)
+
+func TestSchemaToGenaiResolvesRef(t *testing.T) {
+	type ingredient struct {
+		Name     string `json:"name"`
+		Optional bool   `json:"optional"`
+	}
+	type recipe struct {
+		Title       string       `json:"title"`
+		Ingredients []ingredient `json:"ingredients"`
+	}
+
+	s := jsonschema.Reflect(new(recipe))
+	out := schemaToGenai(s)
+
+	ingredients, ok := out.Properties["ingredients"]
+	if !ok {
+		t.Fatal("got no \"ingredients\" property")
+	}
+	if ingredients.Type != genai.TypeArray {
+		t.Fatalf("ingredients.Type = %v, want %v", ingredients.Type, genai.TypeArray)
+	}
+	if ingredients.Items == nil {
+		t.Fatal("ingredients.Items is nil, want the resolved ingredient object schema")
+	}
+	if ingredients.Items.Type != genai.TypeObject {
+		t.Errorf("ingredients.Items.Type = %v, want %v", ingredients.Items.Type, genai.TypeObject)
+	}
+	if _, ok := ingredients.Items.Properties["name"]; !ok {
+		t.Errorf("ingredients.Items.Properties = %#v, want a \"name\" entry", ingredients.Items.Properties)
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	vertexai "cloud.google.com/go/vertexai/genai"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/invopop/jsonschema"
+	"google.golang.org/api/iterator"
+)
+
+// generateVertex is the Vertex AI Gemini dialect of [generate]. The two
+// endpoints accept and return near-identical JSON, but the client SDKs are
+// separate packages, so the request/response translation is duplicated here
+// rather than shared.
+func generateVertex(ctx context.Context, client *vertexai.Client, model string, input *ai.GenerateRequest, cb func(context.Context, *ai.GenerateResponseChunk) error) (*ai.GenerateResponse, error) {
+	gm := client.GenerativeModel(model)
+	configureVertexModel(gm, input)
+
+	parts := toVertexParts(lastMessage(input.Messages))
+	cs := gm.StartChat()
+	if n := len(input.Messages); n > 1 {
+		cs.History = toVertexContent(input.Messages[:n-1])
+	}
+
+	if cb == nil {
+		resp, err := cs.SendMessage(ctx, parts...)
+		if err != nil {
+			return nil, err
+		}
+		if be := blockedErrorVertex(resp); be != nil {
+			return nil, be
+		}
+		return translateVertexResponse(resp, input)
+	}
+
+	var last *vertexai.GenerateContentResponse
+	iter := cs.SendMessageStream(ctx, parts...)
+	for {
+		chunk, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = chunk
+		if err := cb(ctx, translateVertexChunk(chunk)); err != nil {
+			return nil, err
+		}
+	}
+	if be := blockedErrorVertex(last); be != nil {
+		return nil, be
+	}
+	return translateVertexResponse(last, input)
+}
+
+func configureVertexModel(gm *vertexai.GenerativeModel, input *ai.GenerateRequest) {
+	switch c := input.Config.(type) {
+	case *ai.GenerationCommonConfig:
+		applyVertexCommonConfig(gm, c)
+	case *GenerationConfig:
+		applyVertexCommonConfig(gm, &c.GenerationCommonConfig)
+		if c.ResponseMIMEType != "" {
+			gm.ResponseMIMEType = c.ResponseMIMEType
+		}
+		if c.ResponseSchema != nil {
+			gm.ResponseSchema = schemaToVertex(c.ResponseSchema)
+		}
+		if len(c.SafetySettings) > 0 {
+			gm.SafetySettings = toVertexSafetySettings(c.SafetySettings)
+		}
+		if c.SystemInstruction != "" {
+			gm.SystemInstruction = &vertexai.Content{Parts: []vertexai.Part{vertexai.Text(c.SystemInstruction)}}
+		}
+	}
+	if input.Output != nil && input.Output.Schema != nil {
+		gm.ResponseMIMEType = "application/json"
+		gm.ResponseSchema = schemaToVertex(input.Output.Schema)
+	}
+	for _, t := range input.Tools {
+		gm.Tools = append(gm.Tools, &vertexai.Tool{
+			FunctionDeclarations: []*vertexai.FunctionDeclaration{{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  schemaToVertex(t.InputSchema),
+			}},
+		})
+	}
+}
+
+func applyVertexCommonConfig(gm *vertexai.GenerativeModel, gc *ai.GenerationCommonConfig) {
+	if gc == nil {
+		return
+	}
+	if gc.Temperature != 0 {
+		gm.SetTemperature(float32(gc.Temperature))
+	}
+	if gc.TopK != 0 {
+		gm.SetTopK(int32(gc.TopK))
+	}
+	if gc.TopP != 0 {
+		gm.SetTopP(float32(gc.TopP))
+	}
+	if gc.MaxOutputTokens != 0 {
+		gm.SetMaxOutputTokens(int32(gc.MaxOutputTokens))
+	}
+}
+
+// schemaToVertex is the Vertex AI dialect of [schemaToGenai]; see that
+// function's doc comment for the $ref/$defs handling both share.
+func schemaToVertex(s *jsonschema.Schema) *vertexai.Schema {
+	if s == nil {
+		return nil
+	}
+	return schemaToVertexDefs(s, s.Definitions)
+}
+
+func schemaToVertexDefs(s *jsonschema.Schema, defs jsonschema.Definitions) *vertexai.Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		return schemaToVertexDefs(resolveRef(defs, s.Ref), defs)
+	}
+
+	out := &vertexai.Schema{
+		Description: s.Description,
+		Nullable:    false,
+	}
+
+	switch {
+	case len(s.Enum) > 0:
+		out.Type = vertexai.TypeString
+		for _, e := range s.Enum {
+			if str, ok := e.(string); ok {
+				out.Enum = append(out.Enum, str)
+			}
+		}
+	case s.Type == "object" || (s.Type == "" && s.Properties != nil):
+		out.Type = vertexai.TypeObject
+		out.Properties = map[string]*vertexai.Schema{}
+		for pair := s.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			out.Properties[pair.Key] = schemaToVertexDefs(pair.Value, defs)
+		}
+		out.Required = s.Required
+	case s.Type == "array":
+		out.Type = vertexai.TypeArray
+		out.Items = schemaToVertexDefs(s.Items, defs)
+	case s.Type == "integer":
+		out.Type = vertexai.TypeInteger
+	case s.Type == "number":
+		out.Type = vertexai.TypeNumber
+	case s.Type == "boolean":
+		out.Type = vertexai.TypeBoolean
+	default:
+		out.Type = vertexai.TypeString
+	}
+	return out
+}
+
+// toVertexSafetySettings is the Vertex AI dialect of [toGenaiSafetySettings].
+func toVertexSafetySettings(settings []SafetySetting) []*vertexai.SafetySetting {
+	if len(settings) == 0 {
+		return nil
+	}
+	out := make([]*vertexai.SafetySetting, len(settings))
+	for i, s := range settings {
+		out[i] = &vertexai.SafetySetting{
+			Category:  vertexHarmCategory(s.Category),
+			Threshold: vertexHarmBlockThreshold(s.Threshold),
+		}
+	}
+	return out
+}
+
+func vertexHarmCategory(c string) vertexai.HarmCategory {
+	switch c {
+	case HarmCategoryHarassment:
+		return vertexai.HarmCategoryHarassment
+	case HarmCategoryHateSpeech:
+		return vertexai.HarmCategoryHateSpeech
+	case HarmCategorySexuallyExplicit:
+		return vertexai.HarmCategorySexuallyExplicit
+	case HarmCategoryDangerousContent:
+		return vertexai.HarmCategoryDangerousContent
+	default:
+		return vertexai.HarmCategoryUnspecified
+	}
+}
+
+func vertexHarmBlockThreshold(t string) vertexai.HarmBlockThreshold {
+	switch t {
+	case HarmBlockLowAndAbove:
+		return vertexai.HarmBlockLowAndAbove
+	case HarmBlockMediumAndAbove:
+		return vertexai.HarmBlockMediumAndAbove
+	case HarmBlockOnlyHigh:
+		return vertexai.HarmBlockOnlyHigh
+	case HarmBlockNone:
+		return vertexai.HarmBlockNone
+	default:
+		return vertexai.HarmBlockUnspecified
+	}
+}
+
+func toVertexContent(msgs []*ai.Message) []*vertexai.Content {
+	var out []*vertexai.Content
+	for _, m := range msgs {
+		out = append(out, &vertexai.Content{Role: toGenaiRole(m.Role), Parts: toVertexParts(m)})
+	}
+	return out
+}
+
+func toVertexParts(m *ai.Message) []vertexai.Part {
+	var parts []vertexai.Part
+	for _, p := range m.Content {
+		if p.IsText() {
+			parts = append(parts, vertexai.Text(p.Text))
+		}
+	}
+	return parts
+}
+
+func translateVertexChunk(resp *vertexai.GenerateContentResponse) *ai.GenerateResponseChunk {
+	chunk := &ai.GenerateResponseChunk{}
+	if len(resp.Candidates) > 0 {
+		for _, p := range resp.Candidates[0].Content.Parts {
+			if t, ok := p.(vertexai.Text); ok {
+				chunk.Content = append(chunk.Content, ai.NewTextPart(string(t)))
+			}
+		}
+	}
+	// A blocked chunk carries no text, so attach the block metadata to the
+	// chunk itself rather than letting it stream through as silent empty
+	// text; generateVertex still returns the same [BlockedError] once the
+	// stream ends.
+	if be := blockedErrorVertex(resp); be != nil {
+		chunk.Custom = be
+	}
+	return chunk
+}
+
+func translateVertexResponse(resp *vertexai.GenerateContentResponse, input *ai.GenerateRequest) (*ai.GenerateResponse, error) {
+	r := &ai.GenerateResponse{Request: input}
+	if resp == nil {
+		return r, nil
+	}
+	for _, c := range resp.Candidates {
+		m := &ai.Message{Role: ai.RoleModel}
+		if c.Content != nil {
+			for _, p := range c.Content.Parts {
+				if t, ok := p.(vertexai.Text); ok {
+					m.Content = append(m.Content, ai.NewTextPart(string(t)))
+				}
+			}
+		}
+		r.Candidates = append(r.Candidates, &ai.Candidate{
+			Index:        int(c.Index),
+			Message:      m,
+			FinishReason: translateVertexFinishReason(c.FinishReason),
+		})
+	}
+	if u := resp.UsageMetadata; u != nil {
+		r.Usage = &ai.GenerationUsage{
+			InputTokens:  int(u.PromptTokenCount),
+			OutputTokens: int(u.CandidatesTokenCount),
+			TotalTokens:  int(u.TotalTokenCount),
+		}
+	}
+	return r, nil
+}
+
+func translateVertexFinishReason(fr vertexai.FinishReason) ai.FinishReason {
+	switch fr {
+	case vertexai.FinishReasonStop:
+		return ai.FinishReasonStop
+	case vertexai.FinishReasonMaxTokens:
+		return ai.FinishReasonLength
+	case vertexai.FinishReasonSafety, vertexai.FinishReasonRecitation:
+		return ai.FinishReasonBlocked
+	default:
+		return ai.FinishReasonOther
+	}
+}
+
+// blockedErrorVertex is the Vertex AI dialect of [blockedError].
+func blockedErrorVertex(resp *vertexai.GenerateContentResponse) *BlockedError {
+	if resp == nil {
+		return nil
+	}
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != vertexai.BlockReasonUnspecified {
+		return &BlockedError{
+			BlockReason:        fb.BlockReason.String(),
+			BlockReasonMessage: fb.BlockReasonMessage,
+			SafetyRatings:      toSafetyRatingsVertex(fb.SafetyRatings),
+		}
+	}
+	if len(resp.Candidates) == 0 {
+		return nil
+	}
+	// Only treat the response as blocked if every candidate was cut short
+	// by safety filtering; see [blockedError] for why a partial block
+	// isn't treated as a whole-response block.
+	for _, c := range resp.Candidates {
+		switch c.FinishReason {
+		case vertexai.FinishReasonSafety, vertexai.FinishReasonRecitation:
+		default:
+			return nil
+		}
+	}
+	c := resp.Candidates[0]
+	return &BlockedError{
+		SafetyRatings: toSafetyRatingsVertex(c.SafetyRatings),
+		FinishReason:  c.FinishReason.String(),
+	}
+}
+
+func toSafetyRatingsVertex(ratings []*vertexai.SafetyRating) []SafetyRating {
+	if len(ratings) == 0 {
+		return nil
+	}
+	out := make([]SafetyRating, len(ratings))
+	for i, r := range ratings {
+		out[i] = SafetyRating{
+			Category:    r.Category.String(),
+			Probability: r.Probability.String(),
+			Blocked:     r.Blocked,
+		}
+	}
+	return out
+}
+
+// embedVertex is the Vertex AI dialect of [embed]. Vertex's embedding models
+// don't expose a batch-embed RPC the way the Gemini API's BatchEmbedContents
+// does, so each document still gets its own EmbedContent call, but it reuses
+// the same batch-size/concurrency/retry knobs on [Config] to group and pace
+// those calls, just against the Vertex embedding model.
+func embedVertex(ctx context.Context, client *vertexai.Client, cfg *Config, model string, input *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+	em := client.EmbeddingModel(model)
+	if opts, ok := input.Options.(*EmbedOptions); ok && opts != nil {
+		if opts.TaskType != "" {
+			em.TaskType = vertexai.TaskType(opts.TaskType)
+		}
+		if opts.OutputDimensionality != 0 {
+			em.OutputDimensionality = int32(opts.OutputDimensionality)
+		}
+	}
+
+	batchSize := defaultEmbedBatchSize
+	concurrency := 1
+	retry := defaultRetryPolicy
+	if cfg != nil {
+		if cfg.EmbedBatchSize > 0 {
+			batchSize = cfg.EmbedBatchSize
+		}
+		if cfg.EmbedMaxConcurrency > 0 {
+			concurrency = cfg.EmbedMaxConcurrency
+		}
+		if cfg.EmbedRetryPolicy != nil {
+			retry = *cfg.EmbedRetryPolicy
+		}
+	}
+
+	batches := batchDocuments(input.Documents, batchSize)
+	embeddings := make([][]*ai.Embedding, len(batches))
+	errs := make([]error, len(batches))
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, batch []*ai.Document) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			embeddings[i], errs[i] = embedBatchWithRetryVertex(ctx, em, batch, retry)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var all []*ai.Embedding
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("googleai: embedding batch %d of %d: %w", i+1, len(batches), err)
+		}
+		all = append(all, embeddings[i]...)
+	}
+	return &ai.EmbedResponse{Embeddings: all}, nil
+}
+
+// embedBatchWithRetryVertex is the Vertex AI dialect of
+// [embedBatchWithRetry].
+func embedBatchWithRetryVertex(ctx context.Context, em *vertexai.EmbeddingModel, docs []*ai.Document, retry RetryPolicy) ([]*ai.Embedding, error) {
+	var lastErr error
+	for attempt := 0; attempt <= retry.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := retry.delay(attempt, lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		embs, err := embedBatchVertex(ctx, em, docs)
+		if err == nil {
+			return embs, nil
+		}
+		lastErr = err
+		if !isRetryable(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("exceeded %d retries: %w", retry.MaxAttempts, lastErr)
+}
+
+// embedBatchVertex is the Vertex AI dialect of [embedBatch]. It embeds docs
+// one EmbedContent call at a time, since vertexai.EmbeddingModel has no
+// batch-embed RPC to mirror genai.EmbeddingModel's NewBatch/BatchEmbedContents.
+func embedBatchVertex(ctx context.Context, em *vertexai.EmbeddingModel, docs []*ai.Document) ([]*ai.Embedding, error) {
+	embs := make([]*ai.Embedding, len(docs))
+	for i, d := range docs {
+		var parts []vertexai.Part
+		for _, p := range d.Content {
+			if p.IsText() {
+				parts = append(parts, vertexai.Text(p.Text))
+			}
+		}
+		res, err := em.EmbedContent(ctx, parts...)
+		if err != nil {
+			return nil, err
+		}
+		embs[i] = &ai.Embedding{Embedding: res.Embedding.Values}
+	}
+	return embs, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"testing"
+
+	vertexai "cloud.google.com/go/vertexai/genai"
+	"github.com/invopop/jsonschema"
+)
+
+func TestSchemaToVertexResolvesRef(t *testing.T) {
+	type ingredient struct {
+		Name string `json:"name"`
+	}
+	type recipe struct {
+		Ingredients []ingredient `json:"ingredients"`
+	}
+
+	out := schemaToVertex(jsonschema.Reflect(new(recipe)))
+
+	ingredients, ok := out.Properties["ingredients"]
+	if !ok {
+		t.Fatal("got no \"ingredients\" property")
+	}
+	if ingredients.Items == nil || ingredients.Items.Type != vertexai.TypeObject {
+		t.Fatalf("ingredients.Items = %#v, want a resolved object schema", ingredients.Items)
+	}
+	if _, ok := ingredients.Items.Properties["name"]; !ok {
+		t.Errorf("ingredients.Items.Properties = %#v, want a \"name\" entry", ingredients.Items.Properties)
+	}
+}
+
+func TestVertexSafetySettings(t *testing.T) {
+	out := toVertexSafetySettings([]SafetySetting{
+		{Category: HarmCategoryDangerousContent, Threshold: HarmBlockOnlyHigh},
+	})
+	if len(out) != 1 {
+		t.Fatalf("got %d settings, want 1", len(out))
+	}
+	if out[0].Category != vertexai.HarmCategoryDangerousContent {
+		t.Errorf("Category = %v, want %v", out[0].Category, vertexai.HarmCategoryDangerousContent)
+	}
+	if out[0].Threshold != vertexai.HarmBlockOnlyHigh {
+		t.Errorf("Threshold = %v, want %v", out[0].Threshold, vertexai.HarmBlockOnlyHigh)
+	}
+}
+
+func TestBlockedErrorVertexPromptLevel(t *testing.T) {
+	resp := &vertexai.GenerateContentResponse{
+		PromptFeedback: &vertexai.PromptFeedback{
+			BlockReason: vertexai.BlockReasonSafety,
+			SafetyRatings: []*vertexai.SafetyRating{
+				{Category: vertexai.HarmCategoryDangerousContent, Probability: vertexai.HarmProbabilityHigh, Blocked: true},
+			},
+		},
+	}
+	be := blockedErrorVertex(resp)
+	if be == nil {
+		t.Fatal("got nil, want a *BlockedError")
+	}
+	if be.BlockReason != "SAFETY" {
+		t.Errorf("BlockReason = %q, want %q", be.BlockReason, "SAFETY")
+	}
+}
+
+func TestBlockedErrorVertexPartiallyBlocked(t *testing.T) {
+	resp := &vertexai.GenerateContentResponse{
+		Candidates: []*vertexai.Candidate{
+			{FinishReason: vertexai.FinishReasonStop},
+			{FinishReason: vertexai.FinishReasonSafety},
+		},
+	}
+	if be := blockedErrorVertex(resp); be != nil {
+		t.Errorf("got %#v, want nil when at least one candidate wasn't blocked", be)
+	}
+}
+
+func TestBlockedErrorVertexUnblocked(t *testing.T) {
+	resp := &vertexai.GenerateContentResponse{
+		Candidates: []*vertexai.Candidate{
+			{FinishReason: vertexai.FinishReasonStop},
+		},
+	}
+	if be := blockedErrorVertex(resp); be != nil {
+		t.Errorf("got %#v, want nil for an unblocked response", be)
+	}
+}